@@ -0,0 +1,23 @@
+// Package container defines the stats collected for a monitored container.
+package container
+
+// Stats holds a single container measurement, or the aggregate of several
+// measurements over a longer period.
+type Stats struct {
+	Name        string  `json:"n"`
+	Cpu         float64 `json:"c"`
+	Mem         float64 `json:"m"`
+	NetworkSent float64 `json:"ns"`
+	NetworkRecv float64 `json:"nr"`
+
+	// SampleCount is the number of raw measurements this record summarizes.
+	SampleCount int `json:"sc,omitempty"`
+
+	// Max/P95 fields capture the spikes a plain mean hides.
+	CpuMax         float64 `json:"cpuMax,omitempty"`
+	CpuP95         float64 `json:"cpuP95,omitempty"`
+	MemMax         float64 `json:"mMax,omitempty"`
+	MemP95         float64 `json:"mP95,omitempty"`
+	NetworkSentMax float64 `json:"nsMax,omitempty"`
+	NetworkRecvMax float64 `json:"nrMax,omitempty"`
+}
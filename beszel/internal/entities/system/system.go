@@ -0,0 +1,46 @@
+// Package system defines the stats collected for a monitored system.
+package system
+
+// FsStats holds usage stats for a single mounted filesystem.
+type FsStats struct {
+	DiskTotal   float64 `json:"dt,omitempty"`
+	DiskUsed    float64 `json:"du,omitempty"`
+	DiskReadPs  float64 `json:"r,omitempty"`
+	DiskWritePs float64 `json:"w,omitempty"`
+}
+
+// Stats holds a single system measurement, or the aggregate of several
+// measurements over a longer period.
+type Stats struct {
+	Cpu          float64             `json:"cpu"`
+	Mem          float64             `json:"m"`
+	MemUsed      float64             `json:"mu"`
+	MemPct       float64             `json:"mp"`
+	MemBuffCache float64             `json:"mb"`
+	Swap         float64             `json:"s"`
+	SwapUsed     float64             `json:"su"`
+	DiskTotal    float64             `json:"d"`
+	DiskUsed     float64             `json:"du"`
+	DiskPct      float64             `json:"dp"`
+	DiskReadPs   float64             `json:"dr"`
+	DiskWritePs  float64             `json:"dw"`
+	NetworkSent  float64             `json:"ns"`
+	NetworkRecv  float64             `json:"nr"`
+	Temperatures map[string]float64  `json:"t,omitempty"`
+	ExtraFs      map[string]*FsStats `json:"efs,omitempty"`
+
+	// SampleCount is the number of raw measurements this record summarizes.
+	// It is unset (0) for a raw sample, which aggregation counts as one
+	// measurement, and grows as longer records are built from shorter ones,
+	// so the UI can distinguish sparse vs dense buckets.
+	SampleCount int `json:"sc,omitempty"`
+
+	// Max/P95 fields capture the spikes a plain mean hides (e.g. a system
+	// pegged at 100% CPU for 30s inside a 10-minute bucket).
+	CpuMax         float64 `json:"cpuMax,omitempty"`
+	CpuP95         float64 `json:"cpuP95,omitempty"`
+	MemMax         float64 `json:"mMax,omitempty"`
+	MemP95         float64 `json:"mP95,omitempty"`
+	NetworkSentMax float64 `json:"nsMax,omitempty"`
+	NetworkRecvMax float64 `json:"nrMax,omitempty"`
+}
@@ -0,0 +1,190 @@
+// Package prom exposes the latest aggregated system and container stats as
+// a Prometheus-compatible /metrics endpoint, so existing Grafana/
+// Alertmanager pipelines can scrape beszel directly instead of re-reading
+// its SQLite tables.
+package prom
+
+import (
+	"beszel/internal/entities/container"
+	"beszel/internal/entities/system"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Registry holds the most recently aggregated stats per system, keyed by
+// system id. RecordManager pushes into it via PublishSystemStats and
+// PublishContainerStats as longer records are written; the /metrics
+// handler only ever reads from it, so scraping never touches the database.
+type Registry struct {
+	mu   sync.RWMutex
+	data map[string]*systemEntry
+}
+
+type systemEntry struct {
+	name       string
+	stats      system.Stats
+	containers []container.Stats
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{data: make(map[string]*systemEntry)}
+}
+
+// PublishSystemStats records the latest system.Stats for systemId. It
+// implements records.StatsPublisher.
+func (r *Registry) PublishSystemStats(systemId, systemName string, stats system.Stats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry := r.entryLocked(systemId)
+	entry.name = systemName
+	entry.stats = stats
+}
+
+// PublishContainerStats records the latest container.Stats for systemId. It
+// implements records.StatsPublisher.
+func (r *Registry) PublishContainerStats(systemId, systemName string, stats []container.Stats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry := r.entryLocked(systemId)
+	entry.name = systemName
+	entry.containers = stats
+}
+
+// entryLocked returns the entry for systemId, creating it if needed. Callers
+// must hold r.mu.
+func (r *Registry) entryLocked(systemId string) *systemEntry {
+	entry, ok := r.data[systemId]
+	if !ok {
+		entry = &systemEntry{}
+		r.data[systemId] = entry
+	}
+	return entry
+}
+
+// Register adds the /metrics route to app's router.
+func (r *Registry) Register(app *pocketbase.PocketBase) {
+	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
+		e.Router.GET("/metrics", func(c echo.Context) error {
+			return c.Blob(http.StatusOK, "text/plain; version=0.0.4", []byte(r.render()))
+		})
+		return nil
+	})
+}
+
+// render formats all tracked systems in Prometheus text exposition format.
+// Samples are grouped by metric family so each family's HELP/TYPE lines
+// appear exactly once, with all of its samples immediately after — the
+// text exposition format rejects a second HELP/TYPE line for the same
+// metric name, which a naive per-sample emit would produce as soon as
+// there is more than one system, sensor, ExtraFs entry, or container.
+func (r *Registry) render() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	systemIds := make([]string, 0, len(r.data))
+	for systemId := range r.data {
+		systemIds = append(systemIds, systemId)
+	}
+	sort.Strings(systemIds)
+
+	mb := newMetricsBuilder()
+	for _, id := range systemIds {
+		entry := r.data[id]
+		writeSystemStats(mb, id, entry.name, entry.stats)
+		writeContainerStats(mb, id, entry.name, entry.containers)
+	}
+	return mb.render()
+}
+
+// metricsBuilder accumulates gauge samples grouped by metric family name,
+// in first-seen order, so render can emit each family's HELP/TYPE once.
+type metricsBuilder struct {
+	order    []string
+	families map[string]*metricFamily
+}
+
+type metricFamily struct {
+	help  string
+	lines []string
+}
+
+func newMetricsBuilder() *metricsBuilder {
+	return &metricsBuilder{families: make(map[string]*metricFamily)}
+}
+
+func (mb *metricsBuilder) gauge(name, help, labels string, value float64) {
+	f, ok := mb.families[name]
+	if !ok {
+		f = &metricFamily{help: help}
+		mb.families[name] = f
+		mb.order = append(mb.order, name)
+	}
+	f.lines = append(f.lines, fmt.Sprintf("%s{%s} %v", name, labels, value))
+}
+
+func (mb *metricsBuilder) render() string {
+	var b strings.Builder
+	for _, name := range mb.order {
+		f := mb.families[name]
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, f.help, name)
+		for _, line := range f.lines {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+func writeSystemStats(mb *metricsBuilder, systemId, systemName string, stats system.Stats) {
+	labels := fmt.Sprintf(`system="%s"`, escape(systemName))
+	mb.gauge("beszel_cpu_percent", "CPU usage percent", labels, stats.Cpu)
+	mb.gauge("beszel_mem_total_bytes", "Total memory in bytes", labels, stats.Mem)
+	mb.gauge("beszel_mem_used_bytes", "Used memory in bytes", labels, stats.MemUsed)
+	mb.gauge("beszel_mem_percent", "Memory usage percent", labels, stats.MemPct)
+	mb.gauge("beszel_mem_buff_cache_bytes", "Buffer/cache memory in bytes", labels, stats.MemBuffCache)
+	mb.gauge("beszel_swap_total_bytes", "Total swap in bytes", labels, stats.Swap)
+	mb.gauge("beszel_swap_used_bytes", "Used swap in bytes", labels, stats.SwapUsed)
+	mb.gauge("beszel_disk_total_bytes", "Total disk space in bytes", labels, stats.DiskTotal)
+	mb.gauge("beszel_disk_used_bytes", "Used disk space in bytes", labels, stats.DiskUsed)
+	mb.gauge("beszel_disk_percent", "Disk usage percent", labels, stats.DiskPct)
+	mb.gauge("beszel_disk_read_bytes_per_second", "Disk read rate in bytes/sec", labels, stats.DiskReadPs)
+	mb.gauge("beszel_disk_write_bytes_per_second", "Disk write rate in bytes/sec", labels, stats.DiskWritePs)
+	mb.gauge("beszel_net_sent_bytes_per_second", "Network send rate in bytes/sec", labels, stats.NetworkSent)
+	mb.gauge("beszel_net_recv_bytes_per_second", "Network receive rate in bytes/sec", labels, stats.NetworkRecv)
+
+	for sensor, temp := range stats.Temperatures {
+		mb.gauge("beszel_temperature_celsius", "Sensor temperature in celsius",
+			fmt.Sprintf(`%s,sensor="%s"`, labels, escape(sensor)), temp)
+	}
+
+	for name, fs := range stats.ExtraFs {
+		fsLabels := fmt.Sprintf(`%s,disk="%s"`, labels, escape(name))
+		mb.gauge("beszel_extra_disk_total_bytes", "Total space of an extra filesystem in bytes", fsLabels, fs.DiskTotal)
+		mb.gauge("beszel_extra_disk_used_bytes", "Used space of an extra filesystem in bytes", fsLabels, fs.DiskUsed)
+		mb.gauge("beszel_extra_disk_read_bytes_per_second", "Read rate of an extra filesystem in bytes/sec", fsLabels, fs.DiskReadPs)
+		mb.gauge("beszel_extra_disk_write_bytes_per_second", "Write rate of an extra filesystem in bytes/sec", fsLabels, fs.DiskWritePs)
+	}
+}
+
+func writeContainerStats(mb *metricsBuilder, systemId, systemName string, stats []container.Stats) {
+	for _, c := range stats {
+		labels := fmt.Sprintf(`system="%s",container="%s"`, escape(systemName), escape(c.Name))
+		mb.gauge("beszel_container_cpu_percent", "Container CPU usage percent", labels, c.Cpu)
+		mb.gauge("beszel_container_mem_bytes", "Container memory usage in bytes", labels, c.Mem)
+		mb.gauge("beszel_container_net_sent_bytes_per_second", "Container network send rate in bytes/sec", labels, c.NetworkSent)
+		mb.gauge("beszel_container_net_recv_bytes_per_second", "Container network receive rate in bytes/sec", labels, c.NetworkRecv)
+	}
+}
+
+func escape(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return replacer.Replace(value)
+}
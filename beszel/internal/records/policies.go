@@ -0,0 +1,96 @@
+package records
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pocketbase/pocketbase"
+)
+
+// RetentionPolicy describes how one longer record type is built from a
+// shorter one, and how long records of the resulting type are kept.
+//
+// SourceType is the record `type` averaged over Bucket to produce TargetType.
+// MinSamples is the number of SourceType records required before a TargetType
+// record is created (mirrors the previous expectedShorterRecords constant).
+// Retention is how long TargetType records are kept before DeleteOldRecords
+// removes them. A policy with an empty SourceType describes no aggregation
+// step at all - it only sets the retention for TargetType, used for the raw
+// record type written directly by the collector (e.g. "1m").
+type RetentionPolicy struct {
+	SourceType string
+	TargetType string
+	Bucket     time.Duration
+	MinSamples int
+	Retention  time.Duration
+}
+
+// retentionPoliciesEnvVar overrides the policies loaded from PocketBase with
+// a JSON-encoded array of RetentionPolicy, e.g.
+//
+//	BESZEL_RETENTION_POLICIES=[{"SourceType":"1m","TargetType":"10m","Bucket":600000000000,"MinSamples":10,"Retention":43200000000000}]
+const retentionPoliciesEnvVar = "BESZEL_RETENTION_POLICIES"
+
+// retentionPoliciesCollection is the PocketBase collection that stores
+// user-configured retention policies, one record per policy.
+const retentionPoliciesCollection = "retention_policies"
+
+// DefaultRetentionPolicies returns the built-in policy chain
+// (1m -> 10m -> 20m -> 120m -> 480m) with the retention windows beszel has
+// always shipped with.
+func DefaultRetentionPolicies() []RetentionPolicy {
+	return []RetentionPolicy{
+		{TargetType: "1m", Retention: time.Hour},
+		{SourceType: "1m", TargetType: "10m", Bucket: 10 * time.Minute, MinSamples: 10, Retention: 12 * time.Hour},
+		{SourceType: "10m", TargetType: "20m", Bucket: 20 * time.Minute, MinSamples: 2, Retention: 24 * time.Hour},
+		{SourceType: "20m", TargetType: "120m", Bucket: 120 * time.Minute, MinSamples: 6, Retention: 7 * 24 * time.Hour},
+		{SourceType: "120m", TargetType: "480m", Bucket: 480 * time.Minute, MinSamples: 4, Retention: 30 * 24 * time.Hour},
+	}
+}
+
+// LoadRetentionPolicies resolves the active retention policies for app,
+// preferring (in order): the BESZEL_RETENTION_POLICIES env var, records in
+// the retention_policies collection, and finally DefaultRetentionPolicies.
+func LoadRetentionPolicies(app *pocketbase.PocketBase) []RetentionPolicy {
+	if raw := os.Getenv(retentionPoliciesEnvVar); raw != "" {
+		var policies []RetentionPolicy
+		if err := json.Unmarshal([]byte(raw), &policies); err != nil {
+			log.Println("failed to parse "+retentionPoliciesEnvVar, "err", err.Error())
+		} else if len(policies) > 0 {
+			return policies
+		}
+	}
+
+	collection, err := app.Dao().FindCollectionByNameOrId(retentionPoliciesCollection)
+	if err != nil {
+		return DefaultRetentionPolicies()
+	}
+
+	records, err := app.Dao().FindRecordsByExpr(collection.Id, nil)
+	if err != nil || len(records) == 0 {
+		return DefaultRetentionPolicies()
+	}
+
+	policies := make([]RetentionPolicy, 0, len(records))
+	for _, record := range records {
+		policies = append(policies, RetentionPolicy{
+			SourceType: record.GetString("source_type"),
+			TargetType: record.GetString("target_type"),
+			Bucket:     time.Duration(record.GetInt("bucket_seconds")) * time.Second,
+			MinSamples: record.GetInt("min_samples"),
+			Retention:  time.Duration(record.GetInt("retention_seconds")) * time.Second,
+		})
+	}
+
+	// FindRecordsByExpr returns records in unspecified order, but
+	// CreateLongerRecords walks this slice assuming it's shortest-to-longest
+	// (the aggregation cascade, and the createEveryRun flag on the first
+	// entry, both depend on it). Sort by Bucket so an admin-entered
+	// collection can't silently build the chain out of order.
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Bucket < policies[j].Bucket })
+
+	return policies
+}
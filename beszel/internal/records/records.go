@@ -4,8 +4,12 @@ package records
 import (
 	"beszel/internal/entities/container"
 	"beszel/internal/entities/system"
+	"beszel/internal/records/sinks"
+	"context"
+	"fmt"
 	"log"
 	"math"
+	"sync/atomic"
 	"time"
 
 	"github.com/pocketbase/dbx"
@@ -15,7 +19,113 @@ import (
 )
 
 type RecordManager struct {
-	app *pocketbase.PocketBase
+	app          *pocketbase.PocketBase
+	policies     []RetentionPolicy
+	publisher    StatsPublisher
+	hookMode     HookMode
+	sinks        []sinks.Sink
+	sinkFailures int64
+}
+
+// AddSink registers an external sink that receives every longer record
+// CreateLongerRecords writes, in addition to saving it to the database.
+// This lets operators keep beszel's own SQLite retention short while
+// streaming full-fidelity history to InfluxDB/VictoriaMetrics/QuestDB or
+// Prometheus remote-write. A failing sink is logged and counted (see
+// SinkFailures) but never fails the aggregation transaction.
+func (rm *RecordManager) AddSink(sink sinks.Sink) {
+	rm.sinks = append(rm.sinks, sink)
+}
+
+// SinkFailures returns how many sink writes have failed since startup.
+func (rm *RecordManager) SinkFailures() int64 {
+	return atomic.LoadInt64(&rm.sinkFailures)
+}
+
+// writeToSinks forwards stats to every registered sink, logging and
+// counting (never returning) failures so a slow or unreachable sink can't
+// abort the aggregation transaction. Callers must not call this from
+// inside a database transaction: sinks may perform a network round-trip,
+// which would otherwise hold the SQLite write lock for its duration.
+func (rm *RecordManager) writeToSinks(systemId, recordType string, ts time.Time, stats any) {
+	for _, sink := range rm.sinks {
+		if err := sink.Write(context.Background(), systemId, recordType, ts, stats); err != nil {
+			atomic.AddInt64(&rm.sinkFailures, 1)
+			log.Println("sink write failed", "err", err.Error())
+		}
+	}
+}
+
+// Flush flushes every registered sink that buffers writes. CreateLongerRecords
+// calls this at the end of every run; callers should also call it on
+// shutdown so buffered records aren't lost across restarts.
+func (rm *RecordManager) Flush() {
+	rm.flushSinks()
+}
+
+// flushSinks flushes every registered sink that buffers writes (see
+// sinks.Flusher), so a run that produces too few lines to fill a batch
+// doesn't leave them sitting in memory indefinitely. Called at the end of
+// every CreateLongerRecords run and should also be called on shutdown.
+func (rm *RecordManager) flushSinks() {
+	for _, sink := range rm.sinks {
+		flusher, ok := sink.(sinks.Flusher)
+		if !ok {
+			continue
+		}
+		if err := flusher.Flush(context.Background()); err != nil {
+			atomic.AddInt64(&rm.sinkFailures, 1)
+			log.Println("sink flush failed", "err", err.Error())
+		}
+	}
+}
+
+// HookMode controls how DeleteOldRecords removes expired records.
+type HookMode int
+
+const (
+	// HookModeBulk issues a single parameterized DELETE per (collection,
+	// type), chunked with LIMIT to keep transactions short. This is the
+	// default: it's dramatically faster than deleting row by row, but it
+	// does not go through models.Record, so PocketBase record hooks (e.g.
+	// OnRecordAfterDeleteRequest) don't fire for the deleted rows.
+	HookModeBulk HookMode = iota
+	// HookModeRecord deletes one record at a time via txDao.DeleteRecord,
+	// same as before this optimization. Use it if something subscribes to
+	// deletion hooks on system_stats/container_stats.
+	HookModeRecord
+)
+
+// deletionChunkSize bounds how many rows a single bulk DELETE removes, so
+// pruning a large retention window doesn't hold one long-running statement.
+const deletionChunkSize = 5000
+
+// SetHookMode changes how DeleteOldRecords removes expired records.
+func (rm *RecordManager) SetHookMode(mode HookMode) {
+	rm.hookMode = mode
+}
+
+// DeletionStats reports how many rows were removed for one (collection,
+// type) pair, so callers can log or emit metrics on pruning.
+type DeletionStats struct {
+	Collection string
+	Type       string
+	Deleted    int
+}
+
+// StatsPublisher receives the latest aggregated stats as longer records are
+// created, so consumers (e.g. the Prometheus exporter) can serve them
+// without querying the database. Implementations must be safe for
+// concurrent use.
+type StatsPublisher interface {
+	PublishSystemStats(systemId, systemName string, stats system.Stats)
+	PublishContainerStats(systemId, systemName string, stats []container.Stats)
+}
+
+// SetStatsPublisher registers a StatsPublisher to receive aggregated stats
+// as CreateLongerRecords writes them. Pass nil to disable publishing.
+func (rm *RecordManager) SetStatsPublisher(publisher StatsPublisher) {
+	rm.publisher = publisher
 }
 
 type LongerRecordData struct {
@@ -23,6 +133,9 @@ type LongerRecordData struct {
 	longerType             string
 	longerTimeDuration     time.Duration
 	expectedShorterRecords int
+	// createEveryRun skips the "does a longer record already exist" check,
+	// since the shortest policy's target is created every run.
+	createEveryRun bool
 }
 
 type RecordDeletionData struct {
@@ -31,37 +144,45 @@ type RecordDeletionData struct {
 }
 
 func NewRecordManager(app *pocketbase.PocketBase) *RecordManager {
-	return &RecordManager{app}
+	return NewRecordManagerWithPolicies(app, LoadRetentionPolicies(app))
+}
+
+// NewRecordManagerWithPolicies creates a RecordManager that builds and prunes
+// records according to the given retention policies instead of the built-in
+// defaults. Use LoadRetentionPolicies to resolve policies from the
+// retention_policies collection and the BESZEL_RETENTION_POLICIES env var.
+func NewRecordManagerWithPolicies(app *pocketbase.PocketBase, policies []RetentionPolicy) *RecordManager {
+	return &RecordManager{app: app, policies: policies}
+}
+
+// pendingSinkWrite is a sink write deferred until after the aggregation
+// transaction commits, so sinks never perform their network round-trip
+// while holding the SQLite write lock.
+type pendingSinkWrite struct {
+	systemId   string
+	recordType string
+	ts         time.Time
+	stats      any
 }
 
 // Create longer records by averaging shorter records
 func (rm *RecordManager) CreateLongerRecords() {
 	// start := time.Now()
-	recordData := []LongerRecordData{
-		{
-			shorterType:            "1m",
-			expectedShorterRecords: 10,
-			longerType:             "10m",
-			longerTimeDuration:     -10 * time.Minute,
-		},
-		{
-			shorterType:            "10m",
-			expectedShorterRecords: 2,
-			longerType:             "20m",
-			longerTimeDuration:     -20 * time.Minute,
-		},
-		{
-			shorterType:            "20m",
-			expectedShorterRecords: 6,
-			longerType:             "120m",
-			longerTimeDuration:     -120 * time.Minute,
-		},
-		{
-			shorterType:            "120m",
-			expectedShorterRecords: 4,
-			longerType:             "480m",
-			longerTimeDuration:     -480 * time.Minute,
-		},
+	var pendingSinkWrites []pendingSinkWrite
+	recordData := make([]LongerRecordData, 0, len(rm.policies))
+	for _, policy := range rm.policies {
+		// a policy with no source type only records a retention window
+		// (e.g. for the raw record type), not an aggregation step
+		if policy.SourceType == "" {
+			continue
+		}
+		recordData = append(recordData, LongerRecordData{
+			shorterType:            policy.SourceType,
+			expectedShorterRecords: policy.MinSamples,
+			longerType:             policy.TargetType,
+			longerTimeDuration:     -policy.Bucket,
+			createEveryRun:         len(recordData) == 0,
+		})
 	}
 	// wrap the operations in a transaction
 	rm.app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
@@ -88,8 +209,8 @@ func (rm *RecordManager) CreateLongerRecords() {
 				shorterRecordPeriod := time.Now().UTC().Add(recordData.longerTimeDuration)
 				// loop through both collections
 				for _, collection := range collections {
-					// check creation time of last longer record if not 10m, since 10m is created every run
-					if recordData.longerType != "10m" {
+					// check creation time of last longer record, unless this policy's target is created every run
+					if !recordData.createEveryRun {
 						lastLongerRecord, err := txDao.FindFirstRecordByFilter(
 							collection.Id,
 							"type = {:type} && system = {:system} && created > {:created}",
@@ -119,9 +240,17 @@ func (rm *RecordManager) CreateLongerRecords() {
 					var stats interface{}
 					switch collection.Name {
 					case "system_stats":
-						stats = rm.AverageSystemStats(allShorterRecords)
+						systemStats := rm.AverageSystemStats(allShorterRecords)
+						stats = systemStats
+						if rm.publisher != nil {
+							rm.publisher.PublishSystemStats(system.Id, system.GetString("name"), systemStats)
+						}
 					case "container_stats":
-						stats = rm.AverageContainerStats(allShorterRecords)
+						containerStats := rm.AverageContainerStats(allShorterRecords)
+						stats = containerStats
+						if rm.publisher != nil {
+							rm.publisher.PublishContainerStats(system.Id, system.GetString("name"), containerStats)
+						}
 					}
 					longerRecord := models.NewRecord(collection)
 					longerRecord.Set("system", system.Id)
@@ -129,6 +258,16 @@ func (rm *RecordManager) CreateLongerRecords() {
 					longerRecord.Set("type", recordData.longerType)
 					if err := txDao.SaveRecord(longerRecord); err != nil {
 						log.Println("failed to save longer record", "err", err.Error())
+					} else if len(rm.sinks) > 0 {
+						// deferred past the transaction: sinks may do a
+						// network round-trip and must not hold the SQLite
+						// write lock while doing it
+						pendingSinkWrites = append(pendingSinkWrites, pendingSinkWrite{
+							systemId:   system.Id,
+							recordType: recordData.longerType,
+							ts:         longerRecord.Created().Time(),
+							stats:      stats,
+						})
 					}
 				}
 			}
@@ -137,6 +276,11 @@ func (rm *RecordManager) CreateLongerRecords() {
 		return nil
 	})
 
+	for _, w := range pendingSinkWrites {
+		rm.writeToSinks(w.systemId, w.recordType, w.ts, w.stats)
+	}
+	rm.flushSinks()
+
 	// log.Println("finished creating longer records", "time (ms)", time.Since(start).Milliseconds())
 }
 
@@ -150,6 +294,18 @@ func (rm *RecordManager) AverageSystemStats(records []*models.Record) system.Sta
 	// use different counter for temps in case some records don't have them
 	tempCount := float64(0)
 
+	// cpuMax/memMax/... track the max-of-maxes across records so a spike
+	// inside a bucket isn't hidden by the mean; cpuReservoir/memReservoir
+	// approximate P95 with bounded memory (see reservoir.go). When a record
+	// is itself an already-aggregated bucket (SampleCount > 0), its P95 is
+	// fed into the reservoir as a representative sample weighted by its
+	// SampleCount (addWeighted), so a bucket built from many raw samples
+	// isn't diluted to the same single vote as one built from few, and its
+	// SampleCount is carried forward.
+	var cpuMax, memMax, netSentMax, netRecvMax float64
+	var cpuReservoir, memReservoir reservoir
+	sampleCount := 0
+
 	var stats system.Stats
 	for _, record := range records {
 		record.UnmarshalJSONField("stats", &stats)
@@ -167,6 +323,26 @@ func (rm *RecordManager) AverageSystemStats(records []*models.Record) system.Sta
 		sum.DiskWritePs += stats.DiskWritePs
 		sum.NetworkSent += stats.NetworkSent
 		sum.NetworkRecv += stats.NetworkRecv
+
+		weight := stats.SampleCount
+		if weight < 1 {
+			weight = 1
+		}
+		sampleCount += weight
+
+		cpuSample, memSample := stats.Cpu, stats.Mem
+		cpuMax = math.Max(cpuMax, math.Max(stats.Cpu, stats.CpuMax))
+		memMax = math.Max(memMax, math.Max(stats.Mem, stats.MemMax))
+		netSentMax = math.Max(netSentMax, math.Max(stats.NetworkSent, stats.NetworkSentMax))
+		netRecvMax = math.Max(netRecvMax, math.Max(stats.NetworkRecv, stats.NetworkRecvMax))
+		sampleWeight := 1
+		if stats.SampleCount > 0 {
+			cpuSample, memSample = stats.CpuP95, stats.MemP95
+			sampleWeight = weight
+		}
+		cpuReservoir.addWeighted(cpuSample, sampleWeight)
+		memReservoir.addWeighted(memSample, sampleWeight)
+
 		// add temps to sum
 		if stats.Temperatures != nil {
 			tempCount++
@@ -206,6 +382,14 @@ func (rm *RecordManager) AverageSystemStats(records []*models.Record) system.Sta
 		DiskWritePs:  twoDecimals(sum.DiskWritePs / count),
 		NetworkSent:  twoDecimals(sum.NetworkSent / count),
 		NetworkRecv:  twoDecimals(sum.NetworkRecv / count),
+
+		SampleCount:    sampleCount,
+		CpuMax:         twoDecimals(cpuMax),
+		CpuP95:         twoDecimals(cpuReservoir.p95()),
+		MemMax:         twoDecimals(memMax),
+		MemP95:         twoDecimals(memReservoir.p95()),
+		NetworkSentMax: twoDecimals(netSentMax),
+		NetworkRecvMax: twoDecimals(netRecvMax),
 	}
 
 	if len(sum.Temperatures) != 0 {
@@ -230,9 +414,19 @@ func (rm *RecordManager) AverageSystemStats(records []*models.Record) system.Sta
 	return stats
 }
 
+// containerSpread tracks the max/P95 state for one container name while
+// AverageContainerStats iterates records, kept separate from the plain sums
+// so the reservoirs aren't confused with accumulated totals.
+type containerSpread struct {
+	cpuMax, memMax, netSentMax, netRecvMax float64
+	cpuReservoir, memReservoir             reservoir
+	sampleCount                            int
+}
+
 // Calculate the average stats of a list of container_stats records
 func (rm *RecordManager) AverageContainerStats(records []*models.Record) (stats []container.Stats) {
 	sums := make(map[string]*container.Stats)
+	spreads := make(map[string]*containerSpread)
 	count := float64(len(records))
 
 	var containerStats []container.Stats
@@ -241,74 +435,150 @@ func (rm *RecordManager) AverageContainerStats(records []*models.Record) (stats
 		for _, stat := range containerStats {
 			if _, ok := sums[stat.Name]; !ok {
 				sums[stat.Name] = &container.Stats{Name: stat.Name, Cpu: 0, Mem: 0}
+				spreads[stat.Name] = &containerSpread{}
 			}
 			sums[stat.Name].Cpu += stat.Cpu
 			sums[stat.Name].Mem += stat.Mem
 			sums[stat.Name].NetworkSent += stat.NetworkSent
 			sums[stat.Name].NetworkRecv += stat.NetworkRecv
+
+			spread := spreads[stat.Name]
+			weight := stat.SampleCount
+			if weight < 1 {
+				weight = 1
+			}
+			spread.sampleCount += weight
+
+			cpuSample, memSample := stat.Cpu, stat.Mem
+			spread.cpuMax = math.Max(spread.cpuMax, math.Max(stat.Cpu, stat.CpuMax))
+			spread.memMax = math.Max(spread.memMax, math.Max(stat.Mem, stat.MemMax))
+			spread.netSentMax = math.Max(spread.netSentMax, math.Max(stat.NetworkSent, stat.NetworkSentMax))
+			spread.netRecvMax = math.Max(spread.netRecvMax, math.Max(stat.NetworkRecv, stat.NetworkRecvMax))
+			sampleWeight := 1
+			if stat.SampleCount > 0 {
+				cpuSample, memSample = stat.CpuP95, stat.MemP95
+				sampleWeight = weight
+			}
+			spread.cpuReservoir.addWeighted(cpuSample, sampleWeight)
+			spread.memReservoir.addWeighted(memSample, sampleWeight)
 		}
 	}
 
 	for _, value := range sums {
+		spread := spreads[value.Name]
 		stats = append(stats, container.Stats{
-			Name:        value.Name,
-			Cpu:         twoDecimals(value.Cpu / count),
-			Mem:         twoDecimals(value.Mem / count),
-			NetworkSent: twoDecimals(value.NetworkSent / count),
-			NetworkRecv: twoDecimals(value.NetworkRecv / count),
+			Name:           value.Name,
+			Cpu:            twoDecimals(value.Cpu / count),
+			Mem:            twoDecimals(value.Mem / count),
+			NetworkSent:    twoDecimals(value.NetworkSent / count),
+			NetworkRecv:    twoDecimals(value.NetworkRecv / count),
+			SampleCount:    spread.sampleCount,
+			CpuMax:         twoDecimals(spread.cpuMax),
+			CpuP95:         twoDecimals(spread.cpuReservoir.p95()),
+			MemMax:         twoDecimals(spread.memMax),
+			MemP95:         twoDecimals(spread.memReservoir.p95()),
+			NetworkSentMax: twoDecimals(spread.netSentMax),
+			NetworkRecvMax: twoDecimals(spread.netRecvMax),
 		})
 	}
 	return stats
 }
 
-func (rm *RecordManager) DeleteOldRecords() {
+// DeleteOldRecords prunes expired records for every configured retention
+// policy and returns how many rows were removed per (collection, type).
+func (rm *RecordManager) DeleteOldRecords() []DeletionStats {
 	// start := time.Now()
 	collections := []string{"system_stats", "container_stats"}
-	recordData := []RecordDeletionData{
-		{
-			recordType: "1m",
-			retention:  time.Hour,
-		},
-		{
-			recordType: "10m",
-			retention:  12 * time.Hour,
-		},
-		{
-			recordType: "20m",
-			retention:  24 * time.Hour,
-		},
-		{
-			recordType: "120m",
-			retention:  7 * 24 * time.Hour,
-		},
-		{
-			recordType: "480m",
-			retention:  30 * 24 * time.Hour,
-		},
+	recordData := make([]RecordDeletionData, 0, len(rm.policies))
+	for _, policy := range rm.policies {
+		recordData = append(recordData, RecordDeletionData{
+			recordType: policy.TargetType,
+			retention:  policy.Retention,
+		})
 	}
-	rm.app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
-		for _, recordData := range recordData {
-			exp := dbx.NewExp(
-				"type = {:type} AND created < {:created}",
-				dbx.Params{"type": recordData.recordType, "created": time.Now().UTC().Add(-recordData.retention)},
-			)
-			for _, collectionSlug := range collections {
-				collectionRecords, err := txDao.FindRecordsByExpr(collectionSlug, exp)
+
+	var results []DeletionStats
+	err := rm.app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		for _, collectionSlug := range collections {
+			for _, recordData := range recordData {
+				created := time.Now().UTC().Add(-recordData.retention)
+				deleted, err := rm.deleteExpiredRecords(txDao, collectionSlug, recordData.recordType, created)
 				if err != nil {
+					rm.app.Logger().Error("Failed to delete records", "err", err.Error())
 					return err
 				}
-				for _, record := range collectionRecords {
-					err := txDao.DeleteRecord(record)
-					if err != nil {
-						rm.app.Logger().Error("Failed to delete records", "err", err.Error())
-						return err
-					}
+				if deleted > 0 {
+					results = append(results, DeletionStats{Collection: collectionSlug, Type: recordData.recordType, Deleted: deleted})
 				}
 			}
 		}
 		return nil
 	})
+	if err != nil {
+		// the transaction rolled back, so nothing was removed
+		return nil
+	}
 	// log.Println("finished deleting old records", "time (ms)", time.Since(start).Milliseconds())
+	return results
+}
+
+// deleteExpiredRecords removes rows of recordType older than created from
+// collectionSlug, using rm.hookMode to pick the bulk or per-record path.
+func (rm *RecordManager) deleteExpiredRecords(txDao *daos.Dao, collectionSlug, recordType string, created time.Time) (int, error) {
+	if rm.hookMode == HookModeRecord {
+		return deleteExpiredRecordsRowByRow(txDao, collectionSlug, recordType, created)
+	}
+	return deleteExpiredRecordsBulk(txDao, collectionSlug, recordType, created)
+}
+
+// deleteExpiredRecordsBulk issues a single parameterized DELETE per chunk
+// instead of loading and deleting each record individually, so a 30-day
+// retention on 1000 systems doesn't turn into tens of thousands of
+// individual statements plus PocketBase hook overhead per row.
+func deleteExpiredRecordsBulk(txDao *daos.Dao, collectionSlug, recordType string, created time.Time) (int, error) {
+	query := fmt.Sprintf(
+		`DELETE FROM %s WHERE rowid IN (SELECT rowid FROM %s WHERE type = {:type} AND created < {:created} LIMIT {:limit})`,
+		collectionSlug, collectionSlug,
+	)
+	total := 0
+	for {
+		result, err := txDao.DB().NewQuery(query).Bind(dbx.Params{
+			"type":    recordType,
+			"created": created,
+			"limit":   deletionChunkSize,
+		}).Execute()
+		if err != nil {
+			return total, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += int(affected)
+		if affected < deletionChunkSize {
+			return total, nil
+		}
+	}
+}
+
+// deleteExpiredRecordsRowByRow is the original per-record path, kept for
+// HookModeRecord so OnRecordAfterDeleteRequest still fires for callers that
+// rely on it.
+func deleteExpiredRecordsRowByRow(txDao *daos.Dao, collectionSlug, recordType string, created time.Time) (int, error) {
+	exp := dbx.NewExp(
+		"type = {:type} AND created < {:created}",
+		dbx.Params{"type": recordType, "created": created},
+	)
+	records, err := txDao.FindRecordsByExpr(collectionSlug, exp)
+	if err != nil {
+		return 0, err
+	}
+	for _, record := range records {
+		if err := txDao.DeleteRecord(record); err != nil {
+			return 0, err
+		}
+	}
+	return len(records), nil
 }
 
 /* Round float to two decimals */
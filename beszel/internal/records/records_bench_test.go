@@ -0,0 +1,187 @@
+package records
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// seedBenchRecords creates a table shaped like system_stats/container_stats
+// and inserts n expired rows plus a handful of fresh ones, so the tests and
+// benchmark below exercise pruning the expired rows without deleting
+// everything.
+func seedBenchRecords(tb testing.TB, db *dbx.DB, n int) {
+	tb.Helper()
+	_, err := db.NewQuery(`CREATE TABLE bench_stats (id INTEGER PRIMARY KEY, type TEXT, created DATETIME)`).Execute()
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	expired := time.Now().UTC().Add(-48 * time.Hour)
+	fresh := time.Now().UTC()
+
+	tx, err := db.Begin()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	stmt, err := tx.Prepare(`INSERT INTO bench_stats (type, created) VALUES (?, ?)`)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if _, err := stmt.Exec("1m", expired); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := stmt.Exec("1m", fresh); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	stmt.Close()
+	if err := tx.Commit(); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func openBenchDB(tb testing.TB) *dbx.DB {
+	tb.Helper()
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return dbx.NewFromDB(sqlDB, "sqlite3")
+}
+
+// countRows counts the remaining rows in bench_stats, so tests can confirm
+// fresh rows were left alone.
+func countRows(tb testing.TB, db *dbx.DB) int {
+	tb.Helper()
+	var n int
+	if err := db.NewQuery(`SELECT COUNT(*) FROM bench_stats`).Row(&n); err != nil {
+		tb.Fatal(err)
+	}
+	return n
+}
+
+// TestDeleteExpiredRecordsBulk exercises the real deleteExpiredRecordsBulk
+// against a plain table (the function only ever issues raw SQL through
+// txDao.DB(), so it doesn't need a registered PocketBase collection). The
+// row count spans multiple deletionChunkSize iterations plus a partial final
+// chunk, so a regression in the chunking loop (off-by-one, early exit,
+// double-counting) would fail this test instead of only showing up as a
+// timing difference.
+func TestDeleteExpiredRecordsBulk(t *testing.T) {
+	const expiredCount = deletionChunkSize*2 + 37
+	const freshCount = 10
+
+	db := openBenchDB(t)
+	defer db.Close()
+	seedBenchRecords(t, db, expiredCount)
+	dao := daos.New(db)
+
+	created := time.Now().UTC().Add(-time.Hour)
+	deleted, err := deleteExpiredRecordsBulk(dao, "bench_stats", "1m", created)
+	if err != nil {
+		t.Fatalf("deleteExpiredRecordsBulk returned error: %v", err)
+	}
+	if deleted != expiredCount {
+		t.Errorf("expected %d deleted rows, got %d", expiredCount, deleted)
+	}
+	if remaining := countRows(t, db); remaining != freshCount {
+		t.Errorf("expected %d fresh rows left untouched, got %d", freshCount, remaining)
+	}
+}
+
+// TestDeleteExpiredRecordsBulk_ExactChunkBoundary covers the case where the
+// expired row count lands exactly on deletionChunkSize, so the loop's
+// "affected < deletionChunkSize" exit condition is exercised at the boundary
+// rather than only with a partial final chunk.
+func TestDeleteExpiredRecordsBulk_ExactChunkBoundary(t *testing.T) {
+	const expiredCount = deletionChunkSize
+	const freshCount = 10
+
+	db := openBenchDB(t)
+	defer db.Close()
+	seedBenchRecords(t, db, expiredCount)
+	dao := daos.New(db)
+
+	created := time.Now().UTC().Add(-time.Hour)
+	deleted, err := deleteExpiredRecordsBulk(dao, "bench_stats", "1m", created)
+	if err != nil {
+		t.Fatalf("deleteExpiredRecordsBulk returned error: %v", err)
+	}
+	if deleted != expiredCount {
+		t.Errorf("expected %d deleted rows, got %d", expiredCount, deleted)
+	}
+	if remaining := countRows(t, db); remaining != freshCount {
+		t.Errorf("expected %d fresh rows left untouched, got %d", freshCount, remaining)
+	}
+}
+
+// deleteBenchRowByRow mirrors deleteExpiredRecordsRowByRow's query pattern
+// (find then delete one at a time). Unlike the bulk path, the real
+// deleteExpiredRecordsRowByRow goes through txDao.FindRecordsByExpr and
+// txDao.DeleteRecord, which hydrate models.Record against a registered
+// PocketBase collection — scaffolding this package's tests don't otherwise
+// set up. This mirror exists only to give BenchmarkDeleteOldRecords a
+// wall-clock comparison; it is not used for correctness assertions.
+func deleteBenchRowByRow(tb testing.TB, db *dbx.DB, created time.Time) int {
+	tb.Helper()
+	var ids []int
+	err := db.NewQuery(`SELECT id FROM bench_stats WHERE type = {:type} AND created < {:created}`).
+		Bind(dbx.Params{"type": "1m", "created": created}).Column(&ids)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for _, id := range ids {
+		if _, err := db.NewQuery(`DELETE FROM bench_stats WHERE id = {:id}`).Bind(dbx.Params{"id": id}).Execute(); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return len(ids)
+}
+
+func BenchmarkDeleteOldRecords(b *testing.B) {
+	const rowCount = 20000
+	created := time.Now().UTC().Add(-time.Hour)
+
+	b.Run(fmt.Sprintf("RowByRow_%d", rowCount), func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			db := openBenchDB(b)
+			seedBenchRecords(b, db, rowCount)
+			b.StartTimer()
+
+			if deleted := deleteBenchRowByRow(b, db, created); deleted != rowCount {
+				b.Fatalf("expected %d deleted, got %d", rowCount, deleted)
+			}
+			db.Close()
+		}
+	})
+
+	b.Run(fmt.Sprintf("Bulk_%d", rowCount), func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			db := openBenchDB(b)
+			seedBenchRecords(b, db, rowCount)
+			dao := daos.New(db)
+			b.StartTimer()
+
+			deleted, err := deleteExpiredRecordsBulk(dao, "bench_stats", "1m", created)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if deleted != rowCount {
+				b.Fatalf("expected %d deleted, got %d", rowCount, deleted)
+			}
+			db.Close()
+		}
+	})
+}
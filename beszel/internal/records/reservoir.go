@@ -0,0 +1,59 @@
+package records
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// reservoirSize bounds the memory used to approximate P95 while iterating
+// records, following Algorithm R (reservoir sampling).
+const reservoirSize = 200
+
+// reservoir is a fixed-size sample of the values seen so far, used to
+// approximate a percentile without storing every value.
+type reservoir struct {
+	samples []float64
+	seen    int
+}
+
+// add records value, replacing a uniformly-random existing sample once the
+// reservoir is full so every value seen has an equal chance of surviving.
+func (r *reservoir) add(value float64) {
+	r.seen++
+	if len(r.samples) < reservoirSize {
+		r.samples = append(r.samples, value)
+		return
+	}
+	if j := rand.Intn(r.seen); j < reservoirSize {
+		r.samples[j] = value
+	}
+}
+
+// addWeighted records value as if it had been observed weight times. This
+// is how a single representative sample fed in from an already-aggregated
+// child bucket (see AverageSystemStats/AverageContainerStats) gets a
+// survival chance proportional to how many raw samples it actually stands
+// for, instead of competing on equal footing with a single raw sample.
+func (r *reservoir) addWeighted(value float64, weight int) {
+	if weight < 1 {
+		weight = 1
+	}
+	for i := 0; i < weight; i++ {
+		r.add(value)
+	}
+}
+
+// p95 returns the 95th percentile of the sampled values, or 0 if empty.
+func (r *reservoir) p95() float64 {
+	if len(r.samples) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), r.samples...)
+	sort.Float64s(sorted)
+	idx := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
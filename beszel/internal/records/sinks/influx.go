@@ -0,0 +1,154 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"beszel/internal/entities/container"
+	"beszel/internal/entities/system"
+)
+
+// influxBatchSize caps how many line-protocol lines InfluxSink buffers
+// before flushing, trading a little latency for far fewer HTTP writes.
+const influxBatchSize = 500
+
+// InfluxSink batches aggregated records as InfluxDB line protocol and POSTs
+// them to a v2 /api/v2/write endpoint (also accepted by VictoriaMetrics and
+// QuestDB).
+type InfluxSink struct {
+	// URL is the full write endpoint, e.g.
+	// http://localhost:8086/api/v2/write?org=myorg&bucket=beszel&precision=ns
+	// (lines carry nanosecond timestamps, so precision must be ns).
+	URL string
+	// Token is sent as `Authorization: Token <Token>` if set.
+	Token  string
+	Client *http.Client
+
+	mu    sync.Mutex
+	lines []string
+}
+
+// NewInfluxSink creates an InfluxSink that writes to url, authenticating
+// with token if non-empty.
+func NewInfluxSink(url, token string) *InfluxSink {
+	return &InfluxSink{URL: url, Token: token, Client: http.DefaultClient}
+}
+
+// NewInfluxSinkFromEnv builds an InfluxSink from BESZEL_INFLUX_URL (the
+// server base URL, without a path), BESZEL_INFLUX_TOKEN, BESZEL_INFLUX_ORG,
+// and BESZEL_INFLUX_BUCKET. It returns nil if BESZEL_INFLUX_URL isn't set.
+// The nil result is not a usable Sink: Write and Flush dereference the
+// receiver and panic on a nil *InfluxSink.
+func NewInfluxSinkFromEnv() *InfluxSink {
+	baseURL := os.Getenv("BESZEL_INFLUX_URL")
+	if baseURL == "" {
+		return nil
+	}
+	org := os.Getenv("BESZEL_INFLUX_ORG")
+	bucket := os.Getenv("BESZEL_INFLUX_BUCKET")
+	token := os.Getenv("BESZEL_INFLUX_TOKEN")
+	writeURL := fmt.Sprintf("%s/api/v2/write?org=%s&bucket=%s&precision=ns", strings.TrimRight(baseURL, "/"), org, bucket)
+	return NewInfluxSink(writeURL, token)
+}
+
+// Write implements Sink. The line is buffered and only sent once
+// influxBatchSize lines have accumulated; call Flush to send early (e.g. on
+// shutdown).
+func (s *InfluxSink) Write(ctx context.Context, systemID, recordType string, ts time.Time, stats any) error {
+	lines, ok := toLineProtocol(systemID, recordType, ts, stats)
+	if !ok {
+		return nil
+	}
+
+	s.mu.Lock()
+	s.lines = append(s.lines, lines...)
+	var batch []string
+	if len(s.lines) >= influxBatchSize {
+		batch, s.lines = s.lines, nil
+	}
+	s.mu.Unlock()
+
+	if batch == nil {
+		return nil
+	}
+	return s.flush(ctx, batch)
+}
+
+// Flush sends any buffered lines immediately.
+func (s *InfluxSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	batch := s.lines
+	s.lines = nil
+	s.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	return s.flush(ctx, batch)
+}
+
+func (s *InfluxSink) flush(ctx context.Context, lines []string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		return err
+	}
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Token "+s.Token)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("influx write failed: %s", resp.Status)
+	}
+	return nil
+}
+
+func toLineProtocol(systemID, recordType string, ts time.Time, stats any) ([]string, bool) {
+	switch v := stats.(type) {
+	case system.Stats:
+		return []string{systemStatsLine(systemID, recordType, ts, v)}, true
+	case []container.Stats:
+		lines := make([]string, 0, len(v))
+		for _, c := range v {
+			lines = append(lines, containerStatsLine(systemID, recordType, ts, c))
+		}
+		return lines, true
+	default:
+		return nil, false
+	}
+}
+
+func systemStatsLine(systemID, recordType string, ts time.Time, s system.Stats) string {
+	return fmt.Sprintf(
+		"system_stats,system=%s,type=%s cpu=%v,cpu_max=%v,mem=%v,mem_used=%v,disk_used=%v,disk_read_ps=%v,disk_write_ps=%v,net_sent=%v,net_recv=%v %d",
+		escape(systemID), escape(recordType),
+		s.Cpu, s.CpuMax, s.Mem, s.MemUsed, s.DiskUsed, s.DiskReadPs, s.DiskWritePs, s.NetworkSent, s.NetworkRecv,
+		ts.UnixNano(),
+	)
+}
+
+func containerStatsLine(systemID, recordType string, ts time.Time, c container.Stats) string {
+	return fmt.Sprintf(
+		"container_stats,system=%s,type=%s,container=%s cpu=%v,mem=%v,net_sent=%v,net_recv=%v %d",
+		escape(systemID), escape(recordType), escape(c.Name),
+		c.Cpu, c.Mem, c.NetworkSent, c.NetworkRecv,
+		ts.UnixNano(),
+	)
+}
+
+// escape line-protocol-escapes a tag value (measurement/field names are
+// always literal constants above, so only tag values need escaping).
+func escape(value string) string {
+	replacer := strings.NewReplacer(` `, `\ `, `,`, `\,`, `=`, `\=`)
+	return replacer.Replace(value)
+}
@@ -0,0 +1,101 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	"google.golang.org/protobuf/proto"
+
+	"beszel/internal/entities/container"
+	"beszel/internal/entities/system"
+)
+
+// PromRemoteSink writes aggregated records to a Prometheus remote-write
+// endpoint. It exists mainly to validate that the Sink interface isn't
+// tailored to line protocol specifically.
+type PromRemoteSink struct {
+	Endpoint string
+	Client   *http.Client
+}
+
+// NewPromRemoteSink creates a PromRemoteSink that writes to endpoint (a full
+// remote-write URL, e.g. http://localhost:9090/api/v1/write).
+func NewPromRemoteSink(endpoint string) *PromRemoteSink {
+	return &PromRemoteSink{Endpoint: endpoint, Client: http.DefaultClient}
+}
+
+// Write implements Sink.
+func (s *PromRemoteSink) Write(ctx context.Context, systemID, recordType string, ts time.Time, stats any) error {
+	series := toTimeSeries(systemID, recordType, ts, stats)
+	if len(series) == 0 {
+		return nil
+	}
+
+	data, err := proto.Marshal(&prompb.WriteRequest{Timeseries: series})
+	if err != nil {
+		return err
+	}
+	compressed := snappy.Encode(nil, data)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint, bytes.NewReader(compressed))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("prometheus remote-write failed: %s", resp.Status)
+	}
+	return nil
+}
+
+func toTimeSeries(systemID, recordType string, ts time.Time, stats any) []prompb.TimeSeries {
+	switch v := stats.(type) {
+	case system.Stats:
+		labels := []prompb.Label{{Name: "system", Value: systemID}, {Name: "type", Value: recordType}}
+		return []prompb.TimeSeries{
+			series("beszel_cpu_percent", labels, v.Cpu, ts),
+			series("beszel_cpu_max_percent", labels, v.CpuMax, ts),
+			series("beszel_mem_used_bytes", labels, v.MemUsed, ts),
+			series("beszel_disk_used_bytes", labels, v.DiskUsed, ts),
+			series("beszel_net_sent_bytes_per_second", labels, v.NetworkSent, ts),
+			series("beszel_net_recv_bytes_per_second", labels, v.NetworkRecv, ts),
+		}
+	case []container.Stats:
+		result := make([]prompb.TimeSeries, 0, len(v)*2)
+		for _, c := range v {
+			labels := []prompb.Label{
+				{Name: "system", Value: systemID},
+				{Name: "type", Value: recordType},
+				{Name: "container", Value: c.Name},
+			}
+			result = append(result,
+				series("beszel_container_cpu_percent", labels, c.Cpu, ts),
+				series("beszel_container_mem_bytes", labels, c.Mem, ts),
+			)
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+func series(name string, labels []prompb.Label, value float64, ts time.Time) prompb.TimeSeries {
+	allLabels := append([]prompb.Label{{Name: "__name__", Value: name}}, labels...)
+	return prompb.TimeSeries{
+		Labels:  allLabels,
+		Samples: []prompb.Sample{{Value: value, Timestamp: ts.UnixMilli()}},
+	}
+}
@@ -0,0 +1,31 @@
+// Package sinks lets beszel stream aggregated records to external
+// time-series stores as they're produced, so operators can keep beszel's
+// own SQLite retention short while still retaining full-fidelity history
+// elsewhere (InfluxDB, VictoriaMetrics, QuestDB, or anything that accepts
+// Prometheus remote-write).
+package sinks
+
+import (
+	"context"
+	"time"
+)
+
+// Sink receives one aggregated record at a time. stats is either a
+// system.Stats or a []container.Stats, matching what RecordManager just
+// wrote to the system_stats/container_stats collections.
+//
+// Implementations should treat Write as best-effort: a failing sink must
+// never block or fail the caller's aggregation transaction. Callers must
+// also never call Write from inside a database transaction, since sinks
+// may perform a network round-trip.
+type Sink interface {
+	Write(ctx context.Context, systemID string, recordType string, ts time.Time, stats any) error
+}
+
+// Flusher is implemented by sinks that buffer writes internally (e.g. to
+// batch them into fewer network round-trips). Callers should invoke Flush
+// at the end of every aggregation run and on shutdown, so a run that never
+// fills a batch doesn't leave records buffered in memory indefinitely.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}